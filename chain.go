@@ -6,8 +6,12 @@ package btcrpcclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -52,14 +56,83 @@ func (c *Client) GetBestBlockHash() (*chainhash.Hash, error) {
 	return c.GetBestBlockHashAsync().Receive()
 }
 
+// getBlockVerbosity translates the legacy (verbose, verboseTx) boolean pair
+// into the single integer verbosity level (0, 1, or 2) understood by the
+// modern getblock RPC.
+func getBlockVerbosity(verbose, verboseTx bool) int {
+	switch {
+	case verboseTx:
+		return 2
+	case verbose:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// legacyGetBlockRequest builds and sends a getblock request using the
+// legacy two-boolean parameter form (verbose, verboseTx) understood by
+// bitcoind/btcd servers that predate the single integer verbosity
+// parameter. It is used as a fallback when the server rejects the modern
+// request, and directly by GetBlockVerbose when verboseTx is set.
+func (c *Client) legacyGetBlockRequest(hash string, verbose,
+	verboseTx bool) ([]byte, error) {
+
+	hashJSON, err := json.Marshal(hash)
+	if err != nil {
+		return nil, err
+	}
+	verboseJSON, err := json.Marshal(verbose)
+	if err != nil {
+		return nil, err
+	}
+	verboseTxJSON, err := json.Marshal(verboseTx)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.RawRequest("getblock", []json.RawMessage{
+		hashJSON, verboseJSON, verboseTxJSON,
+	})
+}
+
+// waitForGetBlockRes waits for the response promised by the future on res,
+// and falls back to the legacy two-boolean getblock request when the
+// server rejects the modern single verbosity parameter used to issue the
+// request. This allows GetBlock, GetBlockVerboseTx and GetBlockVerbose
+// without verboseTx to transparently interoperate with both old and new
+// getblock server implementations.
+func waitForGetBlockRes(client *Client, res chan *response, hash string,
+	verbose, verboseTx bool) ([]byte, error) {
+
+	rawRes, err := receiveFuture(res)
+	if err == nil {
+		return rawRes, nil
+	}
+
+	// Only retry using the legacy request format when the server
+	// rejected the type of the verbosity parameter; any other error is
+	// returned to the caller unmodified.
+	rpcErr, ok := err.(*btcjson.RPCError)
+	if !ok || rpcErr.Code != btcjson.ErrRPCType {
+		return nil, err
+	}
+
+	return client.legacyGetBlockRequest(hash, verbose, verboseTx)
+}
+
 // FutureGetBlockResult is a future promise to deliver the result of a
 // GetBlockAsync RPC invocation (or an applicable error).
-type FutureGetBlockResult chan *response
+type FutureGetBlockResult struct {
+	client   *Client
+	hash     string
+	Response chan *response
+}
 
 // Receive waits for the response promised by the future and returns the raw
 // block requested from the server given its hash.
 func (r FutureGetBlockResult) Receive() (*btcutil.Block, error) {
-	res, err := receiveFuture(r)
+	res, err := waitForGetBlockRes(r.client, r.Response, r.hash, false, false)
 	if err != nil {
 		return nil, err
 	}
@@ -97,8 +170,12 @@ func (c *Client) GetBlockAsync(blockHash *chainhash.Hash) FutureGetBlockResult {
 		hash = blockHash.String()
 	}
 
-	cmd := btcjson.NewGetBlockCmd(hash, btcjson.Bool(false), nil)
-	return c.sendCmd(cmd)
+	cmd := btcjson.NewGetBlockCmd(hash, btcjson.Int(getBlockVerbosity(false, false)))
+	return FutureGetBlockResult{
+		client:   c,
+		hash:     hash,
+		Response: c.sendCmd(cmd),
+	}
 }
 
 // GetBlock returns a raw block from the server given its hash.
@@ -111,12 +188,25 @@ func (c *Client) GetBlock(blockHash *chainhash.Hash) (*btcutil.Block, error) {
 
 // FutureGetBlockVerboseResult is a future promise to deliver the result of a
 // GetBlockVerboseAsync RPC invocation (or an applicable error).
-type FutureGetBlockVerboseResult chan *response
+type FutureGetBlockVerboseResult struct {
+	client    *Client
+	hash      string
+	verboseTx bool
+	Response  chan *response
+}
 
 // Receive waits for the response promised by the future and returns the data
 // structure from the server with information about the requested block.
 func (r FutureGetBlockVerboseResult) Receive() (*btcjson.GetBlockVerboseResult, error) {
-	res, err := receiveFuture(r)
+	var res []byte
+	var err error
+	if r.Response != nil {
+		res, err = waitForGetBlockRes(r.client, r.Response, r.hash, true, r.verboseTx)
+	} else {
+		// verboseTx requests were never sent to the modern endpoint;
+		// see the comment in GetBlockVerboseAsync.
+		res, err = r.client.legacyGetBlockRequest(r.hash, true, r.verboseTx)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -141,14 +231,103 @@ func (c *Client) GetBlockVerboseAsync(blockHash *chainhash.Hash, verboseTx bool)
 		hash = blockHash.String()
 	}
 
-	cmd := btcjson.NewGetBlockCmd(hash, btcjson.Bool(true), &verboseTx)
-	return c.sendCmd(cmd)
+	if verboseTx {
+		// A modern server's verbosity=2 response puts the fully
+		// expanded transactions under the same "tx" key that
+		// GetBlockVerboseTxResult decodes, not the "rawtx" key this
+		// method's *btcjson.GetBlockVerboseResult return type
+		// expects. Asking the modern endpoint for verbosity=2 here
+		// would unmarshal into the wrong shape, so go straight to
+		// the legacy two-boolean request instead of risking it; the
+		// request is issued lazily from Receive.
+		return FutureGetBlockVerboseResult{
+			client:    c,
+			hash:      hash,
+			verboseTx: verboseTx,
+		}
+	}
+
+	cmd := btcjson.NewGetBlockCmd(hash, btcjson.Int(getBlockVerbosity(true, false)))
+	return FutureGetBlockVerboseResult{
+		client:    c,
+		hash:      hash,
+		verboseTx: verboseTx,
+		Response:  c.sendCmd(cmd),
+	}
+}
+
+// FutureGetBlockVerboseTxResult is a future promise to deliver the result of
+// a GetBlockVerboseTxAsync RPC invocation (or an applicable error).
+type FutureGetBlockVerboseTxResult struct {
+	client   *Client
+	hash     string
+	Response chan *response
+}
+
+// Receive waits for the response promised by the future and returns the data
+// structure from the server with information about the requested block and
+// its fully deserialized transactions.
+func (r FutureGetBlockVerboseTxResult) Receive() (*btcjson.GetBlockVerboseTxResult, error) {
+	res, err := waitForGetBlockRes(r.client, r.Response, r.hash, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the raw result into a GetBlockVerboseTxResult. A modern
+	// server's verbosity=2 response carries the expanded transactions
+	// under "tx", while the legacy verbose=true, verboseTx=true response
+	// carries them under "rawtx"; the latter is moved into Tx so both
+	// shapes decode to the same result.
+	var blockResult btcjson.GetBlockVerboseTxResult
+	err = json.Unmarshal(res, &blockResult)
+	if err != nil {
+		return nil, err
+	}
+	if len(blockResult.Tx) == 0 {
+		var legacy struct {
+			RawTx []btcjson.TxRawResult `json:"rawtx"`
+		}
+		if err := json.Unmarshal(res, &legacy); err != nil {
+			return nil, err
+		}
+		blockResult.Tx = legacy.RawTx
+	}
+	return &blockResult, nil
+}
+
+// GetBlockVerboseTxAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetBlockVerboseTx for the blocking version and more details.
+func (c *Client) GetBlockVerboseTxAsync(blockHash *chainhash.Hash) FutureGetBlockVerboseTxResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+
+	cmd := btcjson.NewGetBlockCmd(hash, btcjson.Int(getBlockVerbosity(true, true)))
+	return FutureGetBlockVerboseTxResult{
+		client:   c,
+		hash:     hash,
+		Response: c.sendCmd(cmd),
+	}
+}
+
+// GetBlockVerboseTx returns a data structure from the server with
+// information about a block, with each of its transactions fully
+// deserialized rather than referenced by hash, given its hash.
+//
+// See GetBlockVerbose to retrieve only the transaction hashes instead.
+func (c *Client) GetBlockVerboseTx(blockHash *chainhash.Hash) (*btcjson.GetBlockVerboseTxResult, error) {
+	return c.GetBlockVerboseTxAsync(blockHash).Receive()
 }
 
 // GetBlockVerbose returns a data structure from the server with information
 // about a block given its hash.
 //
-// See GetBlock to retrieve a raw block instead.
+// See GetBlock to retrieve a raw block instead, or GetBlockVerboseTx to
+// retrieve a data structure with fully deserialized transactions.
 func (c *Client) GetBlockVerbose(blockHash *chainhash.Hash, verboseTx bool) (*btcjson.GetBlockVerboseResult, error) {
 	return c.GetBlockVerboseAsync(blockHash, verboseTx).Receive()
 }
@@ -492,3 +671,195 @@ func (c *Client) GetTxOutAsync(txHash *chainhash.Hash, index uint32, mempool boo
 func (c *Client) GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error) {
 	return c.GetTxOutAsync(txHash, index, mempool).Receive()
 }
+
+// FutureGetTxOutSetInfoResult is a future promise to deliver the result of a
+// GetTxOutSetInfoAsync RPC invocation (or an applicable error).
+type FutureGetTxOutSetInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns
+// statistics about the unspent transaction output set.
+func (r FutureGetTxOutSetInfoResult) Receive() (*btcjson.GetTxOutSetInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a gettxoutsetinfo result object.
+	var info btcjson.GetTxOutSetInfoResult
+	err = json.Unmarshal(res, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetTxOutSetInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetTxOutSetInfo for the blocking version and more details.
+func (c *Client) GetTxOutSetInfoAsync() FutureGetTxOutSetInfoResult {
+	cmd := btcjson.NewGetTxOutSetInfoCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetTxOutSetInfo returns statistics about the unspent transaction output
+// set, which callers can use to size and validate batch GetTxOutMulti
+// queries before issuing them.
+func (c *Client) GetTxOutSetInfo() (*btcjson.GetTxOutSetInfoResult, error) {
+	return c.GetTxOutSetInfoAsync().Receive()
+}
+
+// defaultGetTxOutMultiConcurrency is the number of in-flight GetTxOutAsync
+// requests used by GetTxOutMulti when the caller does not specify one via
+// GetTxOutMultiOptions.
+const defaultGetTxOutMultiConcurrency = 10
+
+// GetTxOutMultiOptions specifies the optional parameters to GetTxOutMulti.
+type GetTxOutMultiOptions struct {
+	// Concurrency is the maximum number of GetTxOutAsync requests that
+	// may be outstanding at once. Defaults to
+	// defaultGetTxOutMultiConcurrency when zero or negative.
+	Concurrency int
+
+	// IncludeMempool indicates whether unconfirmed transactions in the
+	// mempool are considered when resolving each outpoint.
+	IncludeMempool bool
+
+	// PerRequestTimeout bounds how long GetTxOutMulti waits on any single
+	// outpoint's GetTxOutAsync call before giving up on it, in which case
+	// GetTxOutMulti returns a timeout error and no results. Zero (the
+	// default) means no
+	// per-request deadline is enforced beyond ctx. Because GetTxOutAsync
+	// has no way to cancel a request already sent to the server, a
+	// lookup that times out keeps running in the background; this only
+	// stops GetTxOutMulti from waiting on it.
+	PerRequestTimeout time.Duration
+}
+
+// getTxOutMultiResult pairs an outpoint with the outcome of resolving it, so
+// results can be collected off of a single channel regardless of which
+// worker produced them.
+type getTxOutMultiResult struct {
+	outpoint wire.OutPoint
+	txOut    *btcjson.GetTxOutResult
+	err      error
+}
+
+// getTxOutWithTimeout resolves a single outpoint via GetTxOutAsync, giving
+// up and returning an error once timeout elapses if it is positive. Giving
+// up locally does not cancel the underlying request, which has no way to
+// be interrupted once sent; the server-side lookup keeps running regardless.
+func (c *Client) getTxOutWithTimeout(op wire.OutPoint, mempool bool,
+	timeout time.Duration) (*btcjson.GetTxOutResult, error) {
+
+	future := c.GetTxOutAsync(&op.Hash, op.Index, mempool)
+	if timeout <= 0 {
+		return future.Receive()
+	}
+
+	type result struct {
+		txOut *btcjson.GetTxOutResult
+		err   error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		txOut, err := future.Receive()
+		resCh <- result{txOut: txOut, err: err}
+	}()
+
+	select {
+	case res := <-resCh:
+		return res.txOut, res.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timed out after %s waiting for gettxout "+
+			"result for outpoint %v", timeout, op)
+	}
+}
+
+// GetTxOutMulti resolves a batch of outpoints to their current unspent
+// output state in a single call. Outpoints that are spent or unknown to the
+// server are present in the returned map with a nil value, mirroring the
+// sentinel handled by FutureGetTxOutResult.Receive.
+//
+// Requests are pipelined over the existing GetTxOutAsync future mechanism
+// with at most opts.Concurrency requests in flight at once, rather than
+// resolving the outpoints one round trip at a time. opts may be nil to
+// accept the defaults.
+//
+// ctx bounds how long GetTxOutMulti waits on the outstanding requests
+// before giving up, and opts.PerRequestTimeout additionally bounds how
+// long it waits on any single outpoint. Once ctx is canceled, or once any
+// single outpoint lookup fails or times out, GetTxOutMulti stops handing
+// out outpoints that no worker has picked up yet and returns. It cannot,
+// however, cancel a GetTxOutAsync request already sent to the server --
+// GetTxOutAsync takes no context -- so any lookup already in flight keeps
+// running in the background even after GetTxOutMulti has returned.
+func (c *Client) GetTxOutMulti(ctx context.Context, outpoints []wire.OutPoint,
+	opts *GetTxOutMultiOptions) (map[wire.OutPoint]*btcjson.GetTxOutResult, error) {
+
+	if opts == nil {
+		opts = &GetTxOutMultiOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultGetTxOutMultiConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan wire.OutPoint)
+	resultCh := make(chan getTxOutMultiResult, len(outpoints))
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for op := range jobs {
+				txOut, err := c.getTxOutWithTimeout(
+					op, opts.IncludeMempool, opts.PerRequestTimeout,
+				)
+				resultCh <- getTxOutMultiResult{
+					outpoint: op,
+					txOut:    txOut,
+					err:      err,
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, op := range outpoints {
+			select {
+			case jobs <- op:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	results := make(map[wire.OutPoint]*btcjson.GetTxOutResult, len(outpoints))
+	for i := 0; i < len(outpoints); i++ {
+		select {
+		case res, ok := <-resultCh:
+			if !ok {
+				return nil, ctx.Err()
+			}
+			if res.err != nil {
+				return nil, res.err
+			}
+			results[res.outpoint] = res.txOut
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return results, nil
+}
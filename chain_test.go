@@ -0,0 +1,463 @@
+// Copyright (c) 2014-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// serializedTestBlock returns the hex-encoded serialization of a minimal,
+// valid block for use as a getblock (verbosity 0) response.
+func serializedTestBlock(t *testing.T) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var block wire.MsgBlock
+	if err := block.Serialize(&buf); err != nil {
+		t.Fatalf("serialize block: %v", err)
+	}
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// rpcRequest mirrors the subset of the JSON-RPC request envelope the mock
+// servers below need to inspect.
+type rpcRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     json.RawMessage   `json:"id"`
+}
+
+// rpcResponse mirrors the JSON-RPC response envelope sent back by the mock
+// servers below.
+type rpcResponse struct {
+	Result json.RawMessage   `json:"result"`
+	Error  *btcjson.RPCError `json:"error"`
+	ID     json.RawMessage   `json:"id"`
+}
+
+// newMockRPCClient spins up an HTTP JSON-RPC server driven by handle and
+// returns a Client wired up to talk to it, along with a cleanup func.
+func newMockRPCClient(t *testing.T, handle func(rpcRequest) rpcResponse) (*Client, func()) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req rpcRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+
+			resp := handle(req)
+			resp.ID = req.ID
+
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				t.Fatalf("encode response: %v", err)
+			}
+		},
+	))
+
+	cfg := &ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}
+	client, err := New(cfg, nil)
+	if err != nil {
+		server.Close()
+		t.Fatalf("New: %v", err)
+	}
+
+	return client, func() {
+		client.Shutdown()
+		client.WaitForShutdown()
+		server.Close()
+	}
+}
+
+// TestGetBlockModernServer verifies that GetBlockVerbose is satisfied by a
+// single round trip against a server that accepts the modern integer
+// verbosity parameter.
+func TestGetBlockModernServer(t *testing.T) {
+	hash := chainhash.Hash{}
+
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		if req.Method != "getblock" {
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+		if len(req.Params) != 2 {
+			t.Fatalf("expected modern two-param request, got %d params",
+				len(req.Params))
+		}
+
+		result := btcjson.GetBlockVerboseResult{Hash: hash.String()}
+		resultJSON, _ := json.Marshal(result)
+		return rpcResponse{Result: resultJSON}
+	})
+	defer cleanup()
+
+	result, err := client.GetBlockVerbose(&hash, false)
+	if err != nil {
+		t.Fatalf("GetBlockVerbose: %v", err)
+	}
+	if result.Hash != hash.String() {
+		t.Fatalf("got hash %q, want %q", result.Hash, hash.String())
+	}
+}
+
+// TestGetBlockLegacyFallback verifies that GetBlockVerbose falls back to the
+// legacy two-boolean getblock request when the server rejects the modern
+// integer verbosity parameter with a type-mismatch error.
+func TestGetBlockLegacyFallback(t *testing.T) {
+	hash := chainhash.Hash{}
+
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		if req.Method != "getblock" {
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+
+		switch len(req.Params) {
+		case 2:
+			// Modern request; simulate a legacy server rejecting
+			// the integer verbosity parameter.
+			return rpcResponse{
+				Error: &btcjson.RPCError{
+					Code:    btcjson.ErrRPCType,
+					Message: "verbosity must be a bool",
+				},
+			}
+		case 3:
+			// Legacy two-boolean fallback request.
+			result := btcjson.GetBlockVerboseResult{Hash: hash.String()}
+			resultJSON, _ := json.Marshal(result)
+			return rpcResponse{Result: resultJSON}
+		default:
+			t.Fatalf("unexpected param count: %d", len(req.Params))
+			return rpcResponse{}
+		}
+	})
+	defer cleanup()
+
+	result, err := client.GetBlockVerbose(&hash, false)
+	if err != nil {
+		t.Fatalf("GetBlockVerbose: %v", err)
+	}
+	if result.Hash != hash.String() {
+		t.Fatalf("got hash %q, want %q", result.Hash, hash.String())
+	}
+}
+
+// TestGetBlockModernServerRawBlock verifies that plain GetBlock (verbosity
+// 0) is satisfied by a single round trip against a modern server.
+func TestGetBlockModernServerRawBlock(t *testing.T) {
+	hash := chainhash.Hash{}
+	blockHex := serializedTestBlock(t)
+
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		if req.Method != "getblock" {
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+		if len(req.Params) != 2 {
+			t.Fatalf("expected modern two-param request, got %d params",
+				len(req.Params))
+		}
+
+		resultJSON, _ := json.Marshal(blockHex)
+		return rpcResponse{Result: resultJSON}
+	})
+	defer cleanup()
+
+	block, err := client.GetBlock(&hash)
+	if err != nil {
+		t.Fatalf("GetBlock: %v", err)
+	}
+	if block.Hash() == nil {
+		t.Fatalf("got nil block hash")
+	}
+}
+
+// TestGetBlockLegacyFallbackRawBlock verifies that plain GetBlock (verbosity
+// 0) falls back to the legacy two-boolean getblock request when the server
+// rejects the modern integer verbosity parameter.
+func TestGetBlockLegacyFallbackRawBlock(t *testing.T) {
+	hash := chainhash.Hash{}
+	blockHex := serializedTestBlock(t)
+
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		if req.Method != "getblock" {
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+
+		switch len(req.Params) {
+		case 2:
+			return rpcResponse{
+				Error: &btcjson.RPCError{
+					Code:    btcjson.ErrRPCType,
+					Message: "verbosity must be a bool",
+				},
+			}
+		case 3:
+			resultJSON, _ := json.Marshal(blockHex)
+			return rpcResponse{Result: resultJSON}
+		default:
+			t.Fatalf("unexpected param count: %d", len(req.Params))
+			return rpcResponse{}
+		}
+	})
+	defer cleanup()
+
+	block, err := client.GetBlock(&hash)
+	if err != nil {
+		t.Fatalf("GetBlock: %v", err)
+	}
+	if block.Hash() == nil {
+		t.Fatalf("got nil block hash")
+	}
+}
+
+// TestGetBlockVerboseFullTx verifies that GetBlockVerbose(hash, true) goes
+// straight to the legacy two-boolean request instead of asking a modern
+// server for verbosity=2, since the verbosity=2 response shape does not
+// match *btcjson.GetBlockVerboseResult, the type this method decodes into.
+func TestGetBlockVerboseFullTx(t *testing.T) {
+	hash := chainhash.Hash{}
+
+	var requests int
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		requests++
+		if req.Method != "getblock" {
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+		if len(req.Params) != 3 {
+			t.Fatalf("expected legacy three-param request, got %d params",
+				len(req.Params))
+		}
+
+		result := btcjson.GetBlockVerboseResult{
+			Hash:  hash.String(),
+			RawTx: []btcjson.TxRawResult{{Txid: hash.String()}},
+		}
+		resultJSON, _ := json.Marshal(result)
+		return rpcResponse{Result: resultJSON}
+	})
+	defer cleanup()
+
+	result, err := client.GetBlockVerbose(&hash, true)
+	if err != nil {
+		t.Fatalf("GetBlockVerbose: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("got %d requests, want exactly 1 (no modern attempt)", requests)
+	}
+	if len(result.RawTx) != 1 || result.RawTx[0].Txid != hash.String() {
+		t.Fatalf("got RawTx %+v, want one fully decoded transaction", result.RawTx)
+	}
+}
+
+// TestGetBlockVerboseTxModernServer verifies that GetBlockVerboseTx issues a
+// verbosity=2 request and decodes the fully expanded transactions returned
+// by a modern server.
+func TestGetBlockVerboseTxModernServer(t *testing.T) {
+	hash := chainhash.Hash{}
+
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		if req.Method != "getblock" {
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+		if len(req.Params) != 2 {
+			t.Fatalf("expected modern two-param request, got %d params",
+				len(req.Params))
+		}
+
+		var verbosity int
+		if err := json.Unmarshal(req.Params[1], &verbosity); err != nil {
+			t.Fatalf("unmarshal verbosity: %v", err)
+		}
+		if verbosity != 2 {
+			t.Fatalf("got verbosity %d, want 2", verbosity)
+		}
+
+		result := btcjson.GetBlockVerboseTxResult{
+			Hash: hash.String(),
+			Tx:   []btcjson.TxRawResult{{Txid: hash.String()}},
+		}
+		resultJSON, _ := json.Marshal(result)
+		return rpcResponse{Result: resultJSON}
+	})
+	defer cleanup()
+
+	result, err := client.GetBlockVerboseTx(&hash)
+	if err != nil {
+		t.Fatalf("GetBlockVerboseTx: %v", err)
+	}
+	if len(result.Tx) != 1 || result.Tx[0].Txid != hash.String() {
+		t.Fatalf("got Tx %+v, want one fully decoded transaction", result.Tx)
+	}
+}
+
+// TestGetBlockVerboseTxLegacyFallback verifies that GetBlockVerboseTx falls
+// back to the legacy verbose=true, verboseTx=true two-boolean request when
+// the server rejects the modern verbosity=2 parameter.
+func TestGetBlockVerboseTxLegacyFallback(t *testing.T) {
+	hash := chainhash.Hash{}
+
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		if req.Method != "getblock" {
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+
+		switch len(req.Params) {
+		case 2:
+			// Modern request; simulate a legacy server rejecting
+			// the integer verbosity parameter.
+			return rpcResponse{
+				Error: &btcjson.RPCError{
+					Code:    btcjson.ErrRPCType,
+					Message: "verbosity must be a bool",
+				},
+			}
+		case 3:
+			// Legacy two-boolean fallback request.
+			result := btcjson.GetBlockVerboseTxResult{
+				Hash: hash.String(),
+				Tx:   []btcjson.TxRawResult{{Txid: hash.String()}},
+			}
+			resultJSON, _ := json.Marshal(result)
+			return rpcResponse{Result: resultJSON}
+		default:
+			t.Fatalf("unexpected param count: %d", len(req.Params))
+			return rpcResponse{}
+		}
+	})
+	defer cleanup()
+
+	result, err := client.GetBlockVerboseTx(&hash)
+	if err != nil {
+		t.Fatalf("GetBlockVerboseTx: %v", err)
+	}
+	if len(result.Tx) != 1 || result.Tx[0].Txid != hash.String() {
+		t.Fatalf("got Tx %+v, want one fully decoded transaction", result.Tx)
+	}
+}
+
+// TestGetTxOutMulti verifies that GetTxOutMulti assembles a map keyed by
+// outpoint regardless of the order in which the underlying GetTxOutAsync
+// calls complete, and that spent/unknown outputs surface as a nil map
+// value via the same "null" sentinel FutureGetTxOutResult.Receive handles.
+func TestGetTxOutMulti(t *testing.T) {
+	var hashes [3]chainhash.Hash
+	for i := range hashes {
+		hashes[i][0] = byte(i + 1)
+	}
+
+	outpoints := []wire.OutPoint{
+		{Hash: hashes[0], Index: 0},
+		{Hash: hashes[1], Index: 1},
+		{Hash: hashes[2], Index: 2},
+	}
+
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		if req.Method != "gettxout" {
+			t.Fatalf("unexpected method: %s", req.Method)
+		}
+
+		var txid string
+		if err := json.Unmarshal(req.Params[0], &txid); err != nil {
+			t.Fatalf("unmarshal txid: %v", err)
+		}
+
+		if txid == hashes[1].String() {
+			// Simulate an already-spent output.
+			return rpcResponse{Result: json.RawMessage("null")}
+		}
+
+		result := btcjson.GetTxOutResult{BestBlock: txid}
+		resultJSON, _ := json.Marshal(result)
+		return rpcResponse{Result: resultJSON}
+	})
+	defer cleanup()
+
+	opts := &GetTxOutMultiOptions{Concurrency: 1}
+	results, err := client.GetTxOutMulti(context.Background(), outpoints, opts)
+	if err != nil {
+		t.Fatalf("GetTxOutMulti: %v", err)
+	}
+	if len(results) != len(outpoints) {
+		t.Fatalf("got %d results, want %d", len(results), len(outpoints))
+	}
+
+	for _, op := range outpoints {
+		txOut, ok := results[op]
+		if !ok {
+			t.Fatalf("missing result for outpoint %v", op)
+		}
+		if op.Hash == hashes[1] {
+			if txOut != nil {
+				t.Fatalf("got %+v for spent outpoint %v, want nil", txOut, op)
+			}
+			continue
+		}
+		if txOut == nil || txOut.BestBlock != op.Hash.String() {
+			t.Fatalf("got %+v for outpoint %v, want BestBlock %q",
+				txOut, op, op.Hash.String())
+		}
+	}
+}
+
+// TestGetTxOutMultiPerRequestTimeout verifies that a single slow outpoint
+// lookup times out on its own, per opts.PerRequestTimeout, and that the
+// timeout makes the whole GetTxOutMulti call return an error.
+func TestGetTxOutMultiPerRequestTimeout(t *testing.T) {
+	var hashes [2]chainhash.Hash
+	for i := range hashes {
+		hashes[i][0] = byte(i + 1)
+	}
+
+	outpoints := []wire.OutPoint{
+		{Hash: hashes[0], Index: 0},
+		{Hash: hashes[1], Index: 1},
+	}
+
+	client, cleanup := newMockRPCClient(t, func(req rpcRequest) rpcResponse {
+		var txid string
+		if err := json.Unmarshal(req.Params[0], &txid); err != nil {
+			t.Fatalf("unmarshal txid: %v", err)
+		}
+
+		if txid == hashes[0].String() {
+			// Never resolves within the test's per-request
+			// timeout below.
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		result := btcjson.GetTxOutResult{BestBlock: txid}
+		resultJSON, _ := json.Marshal(result)
+		return rpcResponse{Result: resultJSON}
+	})
+	defer cleanup()
+
+	opts := &GetTxOutMultiOptions{
+		Concurrency:       2,
+		PerRequestTimeout: 5 * time.Millisecond,
+	}
+	_, err := client.GetTxOutMulti(context.Background(), outpoints, opts)
+	if err == nil {
+		t.Fatalf("GetTxOutMulti: got nil error, want a timeout error")
+	}
+}